@@ -0,0 +1,114 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/openfaas-incubator/go-function-sdk"
+
+	"github.com/alerting/go-cap"
+)
+
+const (
+	// defaultMaxIds is the largest number of alerts in a batch,
+	// unless overridden by CAP_GET_MAX_IDS.
+	defaultMaxIds = 100
+)
+
+// batchItem is the result for a single id of a batch.
+type batchItem struct {
+	Id    string     `json:"id"`
+	Found bool       `json:"found"`
+	Alert *cap.Alert `json:"alert,omitempty"`
+}
+
+// batchResults are the results of a batch, in the order of the ids.
+type batchResults struct {
+	Alerts []*batchItem `json:"alerts"`
+}
+
+// maxIds returns the largest number of alerts that may be requested at once.
+func maxIds() int {
+	if val, err := strconv.Atoi(os.Getenv("CAP_GET_MAX_IDS")); err == nil && val > 0 {
+		return val
+	}
+
+	return defaultMaxIds
+}
+
+// batchIds returns the ids of a batch request, given as repeated
+// id parameters or as a POSTed JSON array. Returns nil if the
+// request is not for a batch.
+func batchIds(req handler.Request, query url.Values) ([]string, error) {
+	var ids []string
+
+	if req.Method == http.MethodPost && len(req.Body) > 0 {
+		if len(query["id"]) > 0 {
+			return nil, InvalidParameterError("id", fmt.Errorf("Expected ids in either the query or the body"))
+		}
+
+		if err := json.Unmarshal(req.Body, &ids); err != nil {
+			return nil, InvalidParameterError("body", fmt.Errorf("Expected a JSON array of ids: %s", err))
+		}
+
+		if len(ids) == 0 {
+			return nil, InvalidParameterError("body", fmt.Errorf("Expected at least one id"))
+		}
+	} else if len(query["id"]) > 1 {
+		ids = query["id"]
+	} else {
+		return nil, nil
+	}
+
+	for _, id := range ids {
+		if id == "" {
+			return nil, InvalidParameterError("id", fmt.Errorf("Expected non-empty ids"))
+		}
+	}
+
+	if max := maxIds(); len(ids) > max {
+		return nil, InvalidParameterError("id", fmt.Errorf("Expected at most %d ids", max))
+	}
+
+	return ids, nil
+}
+
+// handleBatch fetches the alerts for ids, flagging those not found.
+func handleBatch(ids []string, out *output) (handler.Response, error) {
+	if out.format != FormatJSON {
+		return handler.Response{}, InvalidParameterError("format", fmt.Errorf("Batches are only available as %s", FormatJSON))
+	}
+
+	database, err := connection.Database()
+	if err != nil {
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	alerts, err := database.GetAlertsByIds(ids...)
+	if err != nil {
+		connection.Failed(database, err)
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	results := batchResults{
+		Alerts: make([]*batchItem, len(ids)),
+	}
+
+	for i, id := range ids {
+		if alerts[i] != nil {
+			alerts[i].Infos = out.infos(alerts[i].Infos)
+		}
+
+		results.Alerts[i] = &batchItem{
+			Id:    id,
+			Found: alerts[i] != nil,
+			Alert: alerts[i],
+		}
+	}
+
+	return jsonResponse(http.StatusOK, results)
+}
@@ -0,0 +1,120 @@
+package function
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/openfaas-incubator/go-function-sdk"
+)
+
+// setenv sets an environment variable, returning a function restoring
+// its previous value.
+func setenv(name, value string) func() {
+	old, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+
+	return func() {
+		if ok {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestBatchIds(t *testing.T) {
+	tests := []struct {
+		method string
+		query  string
+		body   string
+		max    string
+		ids    []string
+		err    string
+	}{
+		// Not a batch
+		{http.MethodGet, "", "", "", nil, ""},
+		{http.MethodGet, "id=a", "", "", nil, ""},
+		{http.MethodPost, "id=a", "", "", nil, ""},
+
+		// Repeated id parameters
+		{http.MethodGet, "id=a&id=b", "", "", []string{"a", "b"}, ""},
+		{http.MethodGet, "id=b&id=a&id=b", "", "", []string{"b", "a", "b"}, ""},
+		{http.MethodGet, "id=a&id=", "", "", nil, "invalid_parameter id"},
+
+		// POSTed JSON array
+		{http.MethodPost, "", `["a"]`, "", []string{"a"}, ""},
+		{http.MethodPost, "", `["a", "b", "a"]`, "", []string{"a", "b", "a"}, ""},
+		{http.MethodPost, "format=json", `["a", "b"]`, "", []string{"a", "b"}, ""},
+		{http.MethodPost, "", `[]`, "", nil, "invalid_parameter body"},
+		{http.MethodPost, "", `["a", ""]`, "", nil, "invalid_parameter id"},
+		{http.MethodPost, "", `["a",`, "", nil, "invalid_parameter body"},
+		{http.MethodPost, "", `{"ids": ["a"]}`, "", nil, "invalid_parameter body"},
+		{http.MethodPost, "", `[1, 2]`, "", nil, "invalid_parameter body"},
+		{http.MethodPost, "id=a", `["b"]`, "", nil, "invalid_parameter id"},
+
+		// Only POSTed bodies are read
+		{http.MethodGet, "", `["a", "b"]`, "", nil, ""},
+
+		// CAP_GET_MAX_IDS
+		{http.MethodGet, "id=a&id=b&id=c", "", "3", []string{"a", "b", "c"}, ""},
+		{http.MethodGet, "id=a&id=b&id=c", "", "2", nil, "invalid_parameter id"},
+		{http.MethodPost, "", `["a", "b", "c"]`, "2", nil, "invalid_parameter id"},
+		{http.MethodGet, "id=a&id=b&id=c", "", "none", []string{"a", "b", "c"}, ""},
+		{http.MethodGet, "id=a&id=b&id=c", "", "0", []string{"a", "b", "c"}, ""},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer setenv("CAP_GET_MAX_IDS", test.max)()
+
+			query, err := url.ParseQuery(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := handler.Request{
+				Method:      test.method,
+				QueryString: test.query,
+				Body:        []byte(test.body),
+			}
+
+			ids, err := batchIds(req, query)
+			if test.err != "" {
+				if err == nil {
+					t.Errorf("Expected an error for %s %q %s (max %q)", test.method, test.query, test.body, test.max)
+				} else if param := errorParam(t, err); param != test.err {
+					t.Errorf("Unexpected error for %s %q %s (max %q), got: %s, want: %s.", test.method, test.query, test.body, test.max, param, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Errorf("Unexpected error for %s %q %s (max %q): %v", test.method, test.query, test.body, test.max, err)
+				return
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("Unexpected ids for %s %q %s (max %q), got: %q, want: %q.", test.method, test.query, test.body, test.max, ids, test.ids)
+			}
+		}()
+	}
+}
+
+func TestBatchIdsDefaultMax(t *testing.T) {
+	defer setenv("CAP_GET_MAX_IDS", "")()
+
+	query := url.Values{}
+	for i := 0; i <= defaultMaxIds; i++ {
+		query.Add("id", "a")
+	}
+
+	if _, err := batchIds(handler.Request{Method: http.MethodGet}, query); err == nil {
+		t.Errorf("Expected an error for %d ids", len(query["id"]))
+	}
+
+	query["id"] = query["id"][:defaultMaxIds]
+	if ids, err := batchIds(handler.Request{Method: http.MethodGet}, query); err != nil || len(ids) != defaultMaxIds {
+		t.Errorf("Unexpected result for %d ids, got: %d ids (%v), want: %d ids.", len(query["id"]), len(ids), err, defaultMaxIds)
+	}
+}
@@ -0,0 +1,151 @@
+package function
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/openfaas-incubator/go-function-sdk"
+)
+
+// Error codes returned to the client.
+const (
+	ErrorCodeMissingParameter    = "missing_parameter"
+	ErrorCodeUnknownParameter    = "unknown_parameter"
+	ErrorCodeInvalidParameter    = "invalid_parameter"
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeDatabaseUnavailable = "database_unavailable"
+	ErrorCodeInternal            = "internal_error"
+)
+
+// Error is an error that is reported back to the client.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Param      string `json:"param,omitempty"`
+}
+
+func (err *Error) Error() string {
+	if err.Param != "" {
+		return fmt.Sprintf("%s (%s): %s", err.Code, err.Param, err.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// MissingParameterError is returned when a required parameter is not provided.
+func MissingParameterError(param string) *Error {
+	return &Error{
+		StatusCode: http.StatusBadRequest,
+		Code:       ErrorCodeMissingParameter,
+		Message:    "Missing required parameter",
+		Param:      param,
+	}
+}
+
+// UnknownParameterError is returned when a parameter is not supported.
+func UnknownParameterError(param string) *Error {
+	return &Error{
+		StatusCode: http.StatusBadRequest,
+		Code:       ErrorCodeUnknownParameter,
+		Message:    "Unknown parameter",
+		Param:      param,
+	}
+}
+
+// InvalidParameterError is returned when a parameter has an invalid value.
+func InvalidParameterError(param string, err error) *Error {
+	return &Error{
+		StatusCode: http.StatusBadRequest,
+		Code:       ErrorCodeInvalidParameter,
+		Message:    err.Error(),
+		Param:      param,
+	}
+}
+
+// NotFoundError is returned when the requested item does not exist.
+func NotFoundError(message string) *Error {
+	return &Error{
+		StatusCode: http.StatusNotFound,
+		Code:       ErrorCodeNotFound,
+		Message:    message,
+	}
+}
+
+// DatabaseUnavailableError is returned when the database cannot be reached.
+func DatabaseUnavailableError(err error) *Error {
+	log.Printf("Database error: %s", err)
+
+	return &Error{
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       ErrorCodeDatabaseUnavailable,
+		Message:    "The database is currently unavailable",
+	}
+}
+
+// InternalError is returned for any unexpected error.
+func InternalError(err error) *Error {
+	log.Printf("Internal error: %s", err)
+
+	return &Error{
+		StatusCode: http.StatusInternalServerError,
+		Code:       ErrorCodeInternal,
+		Message:    "An internal error occurred",
+	}
+}
+
+// jsonResponse creates a response with v encoded as JSON.
+func jsonResponse(statusCode int, v interface{}) (handler.Response, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return handler.Response{}, err
+	}
+
+	return handler.Response{
+		Body:       b,
+		StatusCode: statusCode,
+		Header: http.Header{
+			"Content-Type": []string{MediaTypeJSON},
+		},
+	}, nil
+}
+
+// xmlResponse creates a response with v encoded as CAP XML.
+func xmlResponse(statusCode int, v interface{}) (handler.Response, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return handler.Response{}, err
+	}
+
+	return handler.Response{
+		Body:       append([]byte(xml.Header), b...),
+		StatusCode: statusCode,
+		Header: http.Header{
+			"Content-Type": []string{MediaTypeCAP},
+		},
+	}, nil
+}
+
+// errorResponse creates the response for err. Errors that are
+// not of type *Error are reported as internal errors.
+func errorResponse(err error) handler.Response {
+	e, ok := err.(*Error)
+	if !ok {
+		e = InternalError(err)
+	}
+
+	res, err := jsonResponse(e.StatusCode, map[string]*Error{"error": e})
+	if err != nil {
+		// Encoding an *Error should never fail, but
+		// we still need to tell the client something.
+		return handler.Response{
+			Body:       []byte(http.StatusText(http.StatusInternalServerError)),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,138 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alerting/go-cap"
+)
+
+// Output formats.
+const (
+	FormatJSON = "json"
+	FormatXML  = "xml"
+)
+
+// Media types of the output formats.
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeCAP  = "application/cap+xml"
+)
+
+// output describes how alerts are returned to the client.
+type output struct {
+	format   string
+	ranges   []cap.LanguageRange
+	derefUri bool
+}
+
+// parseOutput parses the output options of the request.
+func parseOutput(query url.Values, header http.Header) (*output, error) {
+	var out output
+	var err error
+
+	if out.format, err = responseFormat(query.Get("format"), header); err != nil {
+		return nil, InvalidParameterError("format", err)
+	}
+
+	if out.ranges, err = languageRanges(query.Get("lang"), header); err != nil {
+		return nil, err
+	}
+
+	// Inline resource contents are included by default
+	out.derefUri = true
+	if val := query.Get("deref_uri"); val != "" {
+		if out.derefUri, err = strconv.ParseBool(val); err != nil {
+			return nil, InvalidParameterError("deref_uri", errors.New("Expected true or false"))
+		}
+	}
+
+	return &out, nil
+}
+
+// infos applies the output options to the infos of an alert.
+func (out *output) infos(infos []cap.Info) []cap.Info {
+	// Only keep the infos in the preferred language
+	if out.ranges != nil {
+		infos = cap.PreferredInfos(infos, out.ranges)
+	}
+
+	if !out.derefUri {
+		for i := range infos {
+			stripDerefUri(&infos[i])
+		}
+	}
+
+	return infos
+}
+
+// responseFormat determines the output format of the response. The
+// format parameter takes precedence over the Accept header.
+func responseFormat(format string, header http.Header) (string, error) {
+	switch format {
+	case FormatJSON, FormatXML:
+		return format, nil
+	case "":
+		// Negotiate below
+	default:
+		return "", fmt.Errorf("Unknown value %q, expected one of: %s, %s", format, FormatJSON, FormatXML)
+	}
+
+	// Use the acceptable media type with the highest quality that we
+	// can produce, preferring the earliest in the header on ties
+	best, quality := FormatJSON, 0.0
+	for _, accept := range strings.Split(header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if val, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(val, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		if q <= quality {
+			continue
+		}
+
+		switch mediaType {
+		case MediaTypeCAP, "application/xml", "text/xml":
+			best, quality = FormatXML, q
+		case MediaTypeJSON, "application/*", "*/*":
+			best, quality = FormatJSON, q
+		}
+	}
+
+	return best, nil
+}
+
+// languageRanges returns the preferred languages of the client. The lang
+// parameter takes precedence over the Accept-Language header. Returns
+// nil if the client has no preference, and an invalid parameter error
+// naming the source of the value if it cannot be parsed.
+func languageRanges(lang string, header http.Header) ([]cap.LanguageRange, error) {
+	source := "lang"
+	if lang == "" {
+		source = "Accept-Language"
+		lang = header.Get("Accept-Language")
+	}
+
+	if lang == "" {
+		return nil, nil
+	}
+
+	ranges, err := cap.ParseAcceptLanguage(lang)
+	if err != nil {
+		return nil, InvalidParameterError(source, err)
+	}
+
+	return ranges, nil
+}
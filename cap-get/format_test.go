@@ -0,0 +1,59 @@
+package function
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "", FormatJSON, true},
+		{"json", "application/xml", FormatJSON, true},
+		{"xml", "application/json", FormatXML, true},
+		{"yaml", "", "", false},
+
+		// Media types we can produce
+		{"", "application/cap+xml", FormatXML, true},
+		{"", "text/xml", FormatXML, true},
+		{"", "application/*", FormatJSON, true},
+		{"", "*/*", FormatJSON, true},
+		{"", "text/html", FormatJSON, true},
+
+		// The highest quality wins, ties go to the earliest
+		{"", "application/json;q=0.5, application/xml", FormatXML, true},
+		{"", "application/xml;q=0.5, application/json;q=0.8", FormatJSON, true},
+		{"", "application/xml, application/json", FormatXML, true},
+		{"", "application/json;q=0.7, application/xml;q=0.7", FormatJSON, true},
+		{"", "text/html, application/xml;q=0.1", FormatXML, true},
+
+		// Zero and invalid qualities are not acceptable
+		{"", "application/json;q=0, application/xml;q=0.1", FormatXML, true},
+		{"", "application/json;q=0.0, application/xml;q=0.1", FormatXML, true},
+		{"", "application/json;q=0.00, application/xml;q=0.1", FormatXML, true},
+		{"", "application/json;q=high, application/xml;q=0.1", FormatXML, true},
+		{"", "application/json;q=2, application/xml;q=0.1", FormatXML, true},
+		{"", "application/xml;q=0", FormatJSON, true},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		if test.accept != "" {
+			header.Set("Accept", test.accept)
+		}
+
+		format, err := responseFormat(test.format, header)
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("Unexpected result for %q (Accept %q), got: %v, want ok: %t.", test.format, test.accept, err, test.ok)
+			continue
+		}
+
+		if format != test.want {
+			t.Errorf("Unexpected format for %q (Accept %q), got: %s, want: %s.", test.format, test.accept, format, test.want)
+		}
+	}
+}
@@ -1,61 +1,144 @@
 package function
 
 import (
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
-	"os"
+	"sort"
+	"strconv"
 
-	"github.com/urfave/cli"
+	"github.com/openfaas-incubator/go-function-sdk"
 
 	"github.com/alerting/go-cap-process/db"
 	"github.com/alerting/go-cap-process/tasks"
 )
 
-// Handle a serverless request
-func Handle(req []byte) string {
-	var database db.Database
-
-	// Piggy-back off the command line parsing
-	// to get the database object.
-	app := cli.NewApp()
-	app.Flags = tasks.DatabaseFlags
-
-	app.Action = func(c *cli.Context) error {
-		// Connect to the database, if we haven't already
-		var err error
-		database, err = tasks.CreateDatabase(c)
-		if err != nil {
-			return err
-		}
-		return nil
+// connection is the database, shared by all the requests
+// handled by the process. It is configured from the environment.
+var connection = tasks.NewConnection(tasks.DatabaseFromEnv)
+
+// parameters are the query parameters supported by the function.
+var parameters = map[string]bool{
+	"id":         true,
+	"ref":        true,
+	"sender":     true,
+	"identifier": true,
+	"sent":       true,
+	"info_id":    true,
+	"lineage":    true,
+	"format":     true,
+	"lang":       true,
+	"deref_uri":  true,
+}
+
+// checkParameters returns an unknown parameter error for the first
+// parameter, by name, that is not supported.
+func checkParameters(query url.Values) error {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	// Let's get the database, if we need it
-	if err := app.Run([]string{""}); err != nil {
-		log.Fatal(err)
+	for _, name := range names {
+		if !parameters[name] {
+			return UnknownParameterError(name)
+		}
 	}
 
+	return nil
+}
+
+func handle(req handler.Request) (handler.Response, error) {
 	// Parse query string
-	query, err := url.ParseQuery(os.Getenv("Http_Query"))
+	query, err := url.ParseQuery(req.QueryString)
 	if err != nil {
-		log.Fatal(err)
+		return handler.Response{}, InvalidParameterError("query", err)
 	}
 
-	if val, ok := query["id"]; ok {
-		alert, err := database.GetAlertById(val[0])
-		if err != nil {
-			log.Fatal(err)
+	if err := checkParameters(query); err != nil {
+		return handler.Response{}, err
+	}
+
+	out, err := parseOutput(query, req.Header)
+	if err != nil {
+		return handler.Response{}, err
+	}
+
+	// Fetch many alerts at once
+	ids, err := batchIds(req, query)
+	if err != nil {
+		return handler.Response{}, err
+	} else if ids != nil {
+		return handleBatch(ids, out)
+	}
+
+	// Fetch a single info
+	if query.Get("info_id") != "" {
+		return handleInfo(query, out)
+	}
+
+	get, description, err := parseLookup(query)
+	if err != nil {
+		return handler.Response{}, err
+	}
+
+	// Return the history of the alert, rather than the alert itself
+	lineage := false
+	if val := query.Get("lineage"); val != "" {
+		if lineage, err = strconv.ParseBool(val); err != nil {
+			return handler.Response{}, InvalidParameterError("lineage", errors.New("Expected true or false"))
+		}
+
+		if lineage && out.format != FormatJSON {
+			return handler.Response{}, InvalidParameterError("format", fmt.Errorf("Lineages are only available as %s", FormatJSON))
 		}
+	}
 
-		b, err := json.Marshal(alert)
+	// Let's get the database
+	database, err := connection.Database()
+	if err != nil {
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	alert, err := get(database)
+	if err == db.ErrNotFound {
+		return handler.Response{}, NotFoundError("No alert with " + description)
+	} else if err != nil {
+		connection.Failed(database, err)
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	if lineage {
+		res, err := database.GetLineage(alert.Id())
 		if err != nil {
-			log.Fatal(err)
+			connection.Failed(database, err)
+			return handler.Response{}, DatabaseUnavailableError(err)
 		}
 
-		return string(b)
+		for _, item := range res.Alerts {
+			item.Alert.Infos = out.infos(item.Alert.Infos)
+		}
+
+		return jsonResponse(http.StatusOK, res)
+	}
+
+	alert.Infos = out.infos(alert.Infos)
+
+	if out.format == FormatXML {
+		return xmlResponse(http.StatusOK, alert)
+	}
+
+	return jsonResponse(http.StatusOK, alert)
+}
+
+// Handle a serverless request
+func Handle(req handler.Request) (handler.Response, error) {
+	res, err := handle(req)
+	if err != nil {
+		return errorResponse(err), nil
 	}
 
-	log.Fatal("No id provided")
-	return ""
+	return res, nil
 }
@@ -0,0 +1,48 @@
+package function
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/openfaas-incubator/go-function-sdk"
+
+	"github.com/alerting/go-cap"
+	"github.com/alerting/go-cap-process/db"
+)
+
+// handleInfo fetches a single info by its id. As XML, the info
+// is returned as a CAP alert containing only that info.
+func handleInfo(query url.Values, out *output) (handler.Response, error) {
+	for _, param := range []string{"id", "ref", "sender", "identifier", "sent", "lineage"} {
+		if query.Get(param) != "" {
+			return handler.Response{}, InvalidParameterError(param, errors.New("Cannot be combined with info_id"))
+		}
+	}
+
+	database, err := connection.Database()
+	if err != nil {
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	id := query.Get("info_id")
+	details, err := database.GetInfoById(id)
+	if err == db.ErrNotFound {
+		return handler.Response{}, NotFoundError("No info with id " + id)
+	} else if err != nil {
+		connection.Failed(database, err)
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	if !out.derefUri {
+		stripDerefUri(details.Info)
+	}
+
+	if out.format == FormatXML {
+		alert := *details.Alert
+		alert.Infos = []cap.Info{*details.Info}
+		return xmlResponse(http.StatusOK, &alert)
+	}
+
+	return jsonResponse(http.StatusOK, details)
+}
@@ -0,0 +1,65 @@
+package function
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/alerting/go-cap"
+	"github.com/alerting/go-cap-process/db"
+)
+
+// lookup loads a single alert from the database.
+type lookup func(database db.Database) (*cap.Alert, error)
+
+// parseLookup determines how the alert is looked up: by its id, by a
+// CAP reference ("sender,identifier,sent") or by the sender, identifier
+// and sent parameters.
+func parseLookup(query url.Values) (lookup, string, error) {
+	triple := query.Get("sender") != "" || query.Get("identifier") != "" || query.Get("sent") != ""
+
+	given := 0
+	for _, ok := range []bool{query.Get("id") != "", query.Get("ref") != "", triple} {
+		if ok {
+			given++
+		}
+	}
+
+	if given == 0 {
+		return nil, "", MissingParameterError("id")
+	} else if given > 1 {
+		return nil, "", InvalidParameterError("id", errors.New("Expected only one of id, ref or sender/identifier/sent"))
+	}
+
+	if id := query.Get("id"); id != "" {
+		return func(database db.Database) (*cap.Alert, error) {
+			return database.GetAlertById(id)
+		}, "id " + id, nil
+	}
+
+	var str string
+	if ref := query.Get("ref"); ref != "" {
+		str = ref
+	} else {
+		for _, param := range []string{"sender", "identifier", "sent"} {
+			if query.Get(param) == "" {
+				return nil, "", MissingParameterError(param)
+			}
+		}
+
+		str = strings.Join([]string{query.Get("sender"), query.Get("identifier"), query.Get("sent")}, ",")
+	}
+
+	// An unescaped "+" in the timezone of sent is decoded as a space
+	reference, err := cap.ParseReference(strings.Replace(str, " ", "+", -1))
+	if err != nil {
+		if query.Get("ref") != "" {
+			return nil, "", InvalidParameterError("ref", err)
+		}
+		return nil, "", InvalidParameterError("sent", err)
+	}
+
+	return func(database db.Database) (*cap.Alert, error) {
+		return database.GetAlert(reference)
+	}, "reference " + str, nil
+}
@@ -0,0 +1,133 @@
+package function
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/alerting/go-cap"
+	"github.com/alerting/go-cap-process/db"
+)
+
+// recorder is a Database recording the lookups made with it. Only the
+// methods used by the tests are implemented, others panic.
+type recorder struct {
+	db.Database
+	calls []string
+}
+
+func (r *recorder) GetAlertById(id string) (*cap.Alert, error) {
+	r.calls = append(r.calls, fmt.Sprintf("GetAlertById(%s)", id))
+	return nil, db.ErrNotFound
+}
+
+func (r *recorder) GetAlert(reference *cap.Reference) (*cap.Alert, error) {
+	r.calls = append(r.calls, fmt.Sprintf("GetAlert(%s, %s, %s)", reference.Sender, reference.Identifier, reference.Sent.FormatCAP()))
+	return nil, db.ErrNotFound
+}
+
+// errorParam returns the code and parameter of an *Error.
+func errorParam(t *testing.T, err error) string {
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected an *Error, got: %v", err)
+	}
+
+	return e.Code + " " + e.Param
+}
+
+func TestParseLookup(t *testing.T) {
+	tests := []struct {
+		query string
+		call  string
+		err   string
+	}{
+		{"id=abc", "GetAlertById(abc)", ""},
+		{"ref=sender,wind-1,2018-06-01T12:00:00-04:00", "GetAlert(sender, wind-1, 2018-06-01T12:00:00-04:00)", ""},
+		{"sender=sender&identifier=wind-1&sent=2018-06-01T12:00:00-04:00", "GetAlert(sender, wind-1, 2018-06-01T12:00:00-04:00)", ""},
+
+		// An unescaped "+" in the timezone is decoded as a space
+		{"ref=sender,wind-1,2018-06-01T12:00:00+02:00", "GetAlert(sender, wind-1, 2018-06-01T12:00:00+02:00)", ""},
+		{"sender=sender&identifier=wind-1&sent=2018-06-01T12:00:00+02:00", "GetAlert(sender, wind-1, 2018-06-01T12:00:00+02:00)", ""},
+		{"sender=sender&identifier=wind-1&sent=2018-06-01T12:00:00%2B02:00", "GetAlert(sender, wind-1, 2018-06-01T12:00:00+02:00)", ""},
+
+		// Missing and partial lookups
+		{"", "", "missing_parameter id"},
+		{"id=", "", "missing_parameter id"},
+		{"sender=sender&identifier=wind-1", "", "missing_parameter sent"},
+		{"identifier=wind-1&sent=2018-06-01T12:00:00-04:00", "", "missing_parameter sender"},
+		{"sender=sender&sent=2018-06-01T12:00:00-04:00", "", "missing_parameter identifier"},
+
+		// Invalid values
+		{"ref=sender,wind-1", "", "invalid_parameter ref"},
+		{"ref=sender,wind-1,yesterday", "", "invalid_parameter ref"},
+		{"sender=sender&identifier=wind-1&sent=yesterday", "", "invalid_parameter sent"},
+
+		// Only one way of looking up the alert may be used
+		{"id=abc&ref=sender,wind-1,2018-06-01T12:00:00-04:00", "", "invalid_parameter id"},
+		{"id=abc&sender=sender", "", "invalid_parameter id"},
+		{"ref=sender,wind-1,2018-06-01T12:00:00-04:00&sent=2018-06-01T12:00:00-04:00", "", "invalid_parameter id"},
+	}
+
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		get, _, err := parseLookup(query)
+		if test.err != "" {
+			if err == nil {
+				t.Errorf("Expected an error for %q", test.query)
+			} else if param := errorParam(t, err); param != test.err {
+				t.Errorf("Unexpected error for %q, got: %s, want: %s.", test.query, param, test.err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.query, err)
+			continue
+		}
+
+		r := &recorder{}
+		if _, err := get(r); err != db.ErrNotFound {
+			t.Errorf("Unexpected lookup error for %q: %v", test.query, err)
+		}
+
+		if len(r.calls) != 1 || r.calls[0] != test.call {
+			t.Errorf("Unexpected lookup for %q, got: %q, want: %q.", test.query, r.calls, test.call)
+		}
+	}
+}
+
+func TestCheckParameters(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"", ""},
+		{"id=abc&format=xml&lang=fr&deref_uri=false&lineage=true", ""},
+		{"ref=a,b,c&info_id=abc:0", ""},
+		{"sender=a&identifier=b&sent=c", ""},
+		{"id=abc&size=10", "unknown_parameter size"},
+		{"zoom=1&id=abc&alpha=2", "unknown_parameter alpha"},
+		{"ID=abc", "unknown_parameter ID"},
+	}
+
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = checkParameters(query)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for %q: %v", test.query, err)
+			}
+		} else if err == nil {
+			t.Errorf("Expected an error for %q", test.query)
+		} else if param := errorParam(t, err); param != test.err {
+			t.Errorf("Unexpected error for %q, got: %s, want: %s.", test.query, param, test.err)
+		}
+	}
+}
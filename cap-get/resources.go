@@ -0,0 +1,13 @@
+package function
+
+import (
+	"github.com/alerting/go-cap"
+)
+
+// stripDerefUri removes the inline contents of the resources of the
+// info. Clients can fetch them from cap-resource instead.
+func stripDerefUri(info *cap.Info) {
+	for i := range info.Resources {
+		info.Resources[i].DerefUri = nil
+	}
+}
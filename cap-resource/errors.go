@@ -0,0 +1,148 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/openfaas-incubator/go-function-sdk"
+)
+
+// Error codes returned to the client.
+const (
+	ErrorCodeMissingParameter    = "missing_parameter"
+	ErrorCodeInvalidParameter    = "invalid_parameter"
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeDatabaseUnavailable = "database_unavailable"
+	ErrorCodeResourceUnavailable = "resource_unavailable"
+	ErrorCodeInvalidRange        = "invalid_range"
+	ErrorCodeInternal            = "internal_error"
+)
+
+// Error is an error that is reported back to the client.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Param      string `json:"param,omitempty"`
+}
+
+func (err *Error) Error() string {
+	if err.Param != "" {
+		return fmt.Sprintf("%s (%s): %s", err.Code, err.Param, err.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// MissingParameterError is returned when a required parameter is not provided.
+func MissingParameterError(param string) *Error {
+	return &Error{
+		StatusCode: http.StatusBadRequest,
+		Code:       ErrorCodeMissingParameter,
+		Message:    "Missing required parameter",
+		Param:      param,
+	}
+}
+
+// InvalidParameterError is returned when a parameter has an invalid value.
+func InvalidParameterError(param string, err error) *Error {
+	return &Error{
+		StatusCode: http.StatusBadRequest,
+		Code:       ErrorCodeInvalidParameter,
+		Message:    err.Error(),
+		Param:      param,
+	}
+}
+
+// NotFoundError is returned when the requested item does not exist.
+func NotFoundError(message string) *Error {
+	return &Error{
+		StatusCode: http.StatusNotFound,
+		Code:       ErrorCodeNotFound,
+		Message:    message,
+	}
+}
+
+// DatabaseUnavailableError is returned when the database cannot be reached.
+func DatabaseUnavailableError(err error) *Error {
+	log.Printf("Database error: %s", err)
+
+	return &Error{
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       ErrorCodeDatabaseUnavailable,
+		Message:    "The database is currently unavailable",
+	}
+}
+
+// ResourceUnavailableError is returned when the contents of a
+// resource cannot be loaded, or do not match its digest.
+func ResourceUnavailableError(err error) *Error {
+	log.Printf("Resource error: %s", err)
+
+	return &Error{
+		StatusCode: http.StatusBadGateway,
+		Code:       ErrorCodeResourceUnavailable,
+		Message:    "The resource is currently unavailable",
+	}
+}
+
+// InvalidRangeError is returned when the requested range
+// is not within the resource.
+func InvalidRangeError(size int64) *Error {
+	return &Error{
+		StatusCode: http.StatusRequestedRangeNotSatisfiable,
+		Code:       ErrorCodeInvalidRange,
+		Message:    fmt.Sprintf("The range is not within the %d bytes of the resource", size),
+		Param:      "Range",
+	}
+}
+
+// InternalError is returned for any unexpected error.
+func InternalError(err error) *Error {
+	log.Printf("Internal error: %s", err)
+
+	return &Error{
+		StatusCode: http.StatusInternalServerError,
+		Code:       ErrorCodeInternal,
+		Message:    "An internal error occurred",
+	}
+}
+
+// jsonResponse creates a response with v encoded as JSON.
+func jsonResponse(statusCode int, v interface{}) (handler.Response, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return handler.Response{}, err
+	}
+
+	return handler.Response{
+		Body:       b,
+		StatusCode: statusCode,
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+		},
+	}, nil
+}
+
+// errorResponse creates the response for err. Errors that are
+// not of type *Error are reported as internal errors.
+func errorResponse(err error) handler.Response {
+	e, ok := err.(*Error)
+	if !ok {
+		e = InternalError(err)
+	}
+
+	res, err := jsonResponse(e.StatusCode, map[string]*Error{"error": e})
+	if err != nil {
+		// Encoding an *Error should never fail, but
+		// we still need to tell the client something.
+		return handler.Response{
+			Body:       []byte(http.StatusText(http.StatusInternalServerError)),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	return res
+}
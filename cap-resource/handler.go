@@ -0,0 +1,170 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/openfaas-incubator/go-function-sdk"
+
+	"github.com/alerting/go-cap"
+	"github.com/alerting/go-cap-process/db"
+	"github.com/alerting/go-cap-process/store"
+	"github.com/alerting/go-cap-process/tasks"
+)
+
+// connection is the database shared by the invocations of the function.
+var connection = tasks.NewConnection(tasks.DatabaseFromEnv)
+
+// stores holds the resource store shared by the invocations of the
+// function, which is created on first use.
+var stores struct {
+	mu        sync.Mutex
+	resources store.ResourceStore
+}
+
+// resourceStore returns the resource store, creating it if needed.
+// Failures to create it are not kept, the next call tries again.
+func resourceStore() (store.ResourceStore, error) {
+	stores.mu.Lock()
+	defer stores.mu.Unlock()
+
+	if stores.resources == nil {
+		resources, err := tasks.StoreFromEnv()
+		if err != nil {
+			return nil, err
+		}
+
+		stores.resources = resources
+	}
+
+	return stores.resources, nil
+}
+
+func handle(req handler.Request) (handler.Response, error) {
+	// Parse query string
+	query, err := url.ParseQuery(req.QueryString)
+	if err != nil {
+		return handler.Response{}, InvalidParameterError("query", err)
+	}
+
+	// Resources are identified by their digest,
+	// or by the id of their alert and their index.
+	digest := query.Get("digest")
+	id := query.Get("id")
+
+	index := 0
+	if digest != "" && id != "" {
+		return handler.Response{}, InvalidParameterError("digest", errors.New("Expected only one of digest or id"))
+	} else if digest == "" && id == "" {
+		return handler.Response{}, MissingParameterError("id")
+	} else if id != "" {
+		if query.Get("resource") == "" {
+			return handler.Response{}, MissingParameterError("resource")
+		}
+
+		if index, err = strconv.Atoi(query.Get("resource")); err != nil || index < 0 {
+			return handler.Response{}, InvalidParameterError("resource", errors.New("Expected an integer greater than or equal to 0"))
+		}
+	}
+
+	// Let's get the database
+	database, err := connection.Database()
+	if err != nil {
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	resources, err := resourceStore()
+	if err != nil {
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	var resource *cap.Resource
+	var loc *location
+	if digest != "" {
+		resource, loc, err = resourceByDigest(database, digest)
+	} else {
+		resource, loc, err = resourceByIndex(database, id, index)
+	}
+
+	if err == db.ErrNotFound {
+		return handler.Response{}, NotFoundError("No such resource")
+	} else if err != nil {
+		connection.Failed(database, err)
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	sum, err := checksum(database, resource, loc)
+	if err != nil {
+		connection.Failed(database, err)
+		return handler.Response{}, DatabaseUnavailableError(err)
+	}
+
+	r, size, err := contents(resource, sum, resources)
+	if err == errNoContents {
+		return handler.Response{}, NotFoundError("The contents of the resource are not available")
+	} else if err != nil {
+		return handler.Response{}, ResourceUnavailableError(err)
+	}
+	defer r.Close()
+
+	return resourceResponse(req, resource, sum, r, size)
+}
+
+// resourceResponse creates the response with the contents of the
+// resource, or the part of them asked for by the Range header.
+func resourceResponse(req handler.Request, resource *cap.Resource, checksum string, r io.Reader, size int64) (handler.Response, error) {
+	res := handler.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Accept-Ranges": []string{"bytes"},
+			"Content-Type":  []string{"application/octet-stream"},
+		},
+	}
+
+	if resource.MimeType != "" {
+		res.Header.Set("Content-Type", resource.MimeType)
+	}
+
+	if checksum != "" {
+		res.Header.Set("ETag", strconv.Quote(checksum))
+	}
+
+	rng, ok := parseRange(req.Header.Get("Range"), size)
+	if !ok {
+		res = errorResponse(InvalidRangeError(size))
+		res.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return res, nil
+	}
+
+	b, err := read(r, size, rng, checksum)
+	if err != nil {
+		return handler.Response{}, ResourceUnavailableError(err)
+	}
+
+	if rng != nil {
+		res.StatusCode = http.StatusPartialContent
+		res.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end, size))
+	}
+
+	res.Header.Set("Content-Length", strconv.Itoa(len(b)))
+	if req.Method != http.MethodHead {
+		res.Body = b
+	}
+
+	return res, nil
+}
+
+// Handle a serverless request
+func Handle(req handler.Request) (handler.Response, error) {
+	res, err := handle(req)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	return res, nil
+}
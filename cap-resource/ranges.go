@@ -0,0 +1,122 @@
+package function
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// byteRange is a range of bytes of a resource, inclusive.
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+// parseRange parses the Range header for a resource of the given size.
+// Returns nil if the whole resource should be sent, which is the case if
+// there is no header, it is malformed or it asks for multiple ranges.
+// The second return value is false if the range is not satisfiable.
+func parseRange(header string, size int64) (*byteRange, bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil, true
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(header, "bytes="))
+	if strings.Contains(spec, ",") {
+		return nil, true
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return nil, true
+	}
+
+	// Suffix range (ie. the last n bytes)
+	if parts[0] == "" {
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || n < 0 {
+			return nil, true
+		} else if n == 0 || size == 0 {
+			return nil, false
+		}
+
+		if n > size {
+			n = size
+		}
+
+		return &byteRange{start: size - n, end: size - 1}, true
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start < 0 {
+		return nil, true
+	}
+
+	end := size - 1
+	if parts[1] != "" {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil || end < start {
+			return nil, true
+		}
+
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return nil, false
+	}
+
+	return &byteRange{start: start, end: end}, true
+}
+
+// rangeWriter keeps the bytes written to it that are in the range,
+// and discards the others.
+type rangeWriter struct {
+	rng    *byteRange
+	offset int64
+	buf    bytes.Buffer
+}
+
+// newRangeWriter creates a writer for the range (all bytes if nil)
+// of a resource of the given size.
+func newRangeWriter(rng *byteRange, size int64) *rangeWriter {
+	w := &rangeWriter{rng: rng}
+	if rng != nil {
+		w.buf.Grow(int(rng.end - rng.start + 1))
+	} else {
+		w.buf.Grow(int(size))
+	}
+
+	return w
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	start, end := int64(0), int64(n)
+
+	if w.rng != nil {
+		start = clamp(w.rng.start-w.offset, 0, int64(n))
+		end = clamp(w.rng.end+1-w.offset, start, int64(n))
+	}
+
+	w.offset += int64(n)
+	w.buf.Write(p[start:end])
+	return n, nil
+}
+
+// Bytes returns the bytes of the range written so far.
+func (w *rangeWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// clamp returns n, limited to between lo and hi.
+func clamp(n, lo, hi int64) int64 {
+	if n < lo {
+		return lo
+	} else if n > hi {
+		return hi
+	}
+
+	return n
+}
@@ -0,0 +1,88 @@
+package function
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header string
+		size   int64
+		want   *byteRange
+		ok     bool
+	}{
+		// Whole resource
+		{"", 100, nil, true},
+		{"items=0-10", 100, nil, true},
+		{"bytes=a-b", 100, nil, true},
+		{"bytes=10-5", 100, nil, true},
+		{"bytes=5", 100, nil, true},
+
+		// Multiple ranges are not supported, so the whole resource is sent
+		{"bytes=0-10,20-30", 100, nil, true},
+
+		{"bytes=0-9", 100, &byteRange{0, 9}, true},
+		{"bytes=90-200", 100, &byteRange{90, 99}, true},
+
+		// Open ranges
+		{"bytes=10-", 100, &byteRange{10, 99}, true},
+		{"bytes=0-", 1, &byteRange{0, 0}, true},
+
+		// Suffix ranges
+		{"bytes=-10", 100, &byteRange{90, 99}, true},
+		{"bytes=-200", 100, &byteRange{0, 99}, true},
+
+		// Unsatisfiable
+		{"bytes=100-", 100, nil, false},
+		{"bytes=100-200", 100, nil, false},
+		{"bytes=-0", 100, nil, false},
+		{"bytes=-10", 0, nil, false},
+		{"bytes=0-", 0, nil, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseRange(test.header, test.size)
+		if ok != test.ok {
+			t.Errorf("Unexpected satisfiability for %q of %d bytes, got: %t, want: %t.", test.header, test.size, ok, test.ok)
+		}
+
+		if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+			t.Errorf("Unexpected range for %q of %d bytes, got: %+v, want: %+v.", test.header, test.size, got, test.want)
+		}
+	}
+}
+
+func TestRangeWriter(t *testing.T) {
+	contents := []byte("0123456789")
+
+	tests := []struct {
+		rng  *byteRange
+		want string
+	}{
+		{nil, "0123456789"},
+		{&byteRange{0, 0}, "0"},
+		{&byteRange{3, 6}, "3456"},
+		{&byteRange{9, 9}, "9"},
+	}
+
+	for _, test := range tests {
+		for _, oneByte := range []bool{false, true} {
+			var r io.Reader = bytes.NewReader(contents)
+			if oneByte {
+				r = iotest.OneByteReader(r)
+			}
+
+			w := newRangeWriter(test.rng, int64(len(contents)))
+			if _, err := io.Copy(w, r); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := string(w.Bytes()); got != test.want {
+				t.Errorf("Unexpected bytes of %+v (one byte at a time: %t), got: %q, want: %q.", test.rng, oneByte, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package function
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alerting/go-cap"
+	"github.com/alerting/go-cap-process/db"
+	"github.com/alerting/go-cap-process/store"
+)
+
+const (
+	// defaultMaxSize is the largest resource fetched from its uri,
+	// unless overridden by CAP_RESOURCE_MAX_SIZE.
+	defaultMaxSize = 50 * 1024 * 1024
+
+	// fetchTimeout is how long to wait for a remote resource.
+	fetchTimeout = 30 * time.Second
+)
+
+// errNoContents is returned when the contents of a resource are not available.
+var errNoContents = errors.New("No contents available for the resource")
+
+// maxSize returns the largest resource that may be fetched from its uri.
+func maxSize() int64 {
+	if val, err := strconv.ParseInt(os.Getenv("CAP_RESOURCE_MAX_SIZE"), 10, 64); err == nil && val > 0 {
+		return val
+	}
+
+	return defaultMaxSize
+}
+
+// location is where a resource is in its alert.
+type location struct {
+	alertId  string
+	info     int
+	resource int
+}
+
+// resourceByIndex returns the resource at index of the alert, counting
+// the resources of all its infos in order.
+func resourceByIndex(database db.Database, id string, index int) (*cap.Resource, *location, error) {
+	alert, err := database.GetAlertById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, info := range alert.Infos {
+		if index < len(info.Resources) {
+			return &info.Resources[index], &location{alertId: id, info: i, resource: index}, nil
+		}
+		index -= len(info.Resources)
+	}
+
+	return nil, nil, db.ErrNotFound
+}
+
+// resourceByDigest returns a resource with the given digest.
+func resourceByDigest(database db.Database, digest string) (*cap.Resource, *location, error) {
+	res, err := database.NewInfoFinder().ResourceDigests(digest).Count(1).Find()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, hit := range res.Hits {
+		// Ids of infos are <alert id>:<index>
+		info, err := strconv.Atoi(hit.Id[strings.LastIndex(hit.Id, ":")+1:])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range hit.Info.Resources {
+			if strings.EqualFold(hit.Info.Resources[i].Digest, digest) {
+				return &hit.Info.Resources[i], &location{alertId: hit.AlertId, info: info, resource: i}, nil
+			}
+		}
+	}
+
+	return nil, nil, db.ErrNotFound
+}
+
+// checksum returns the checksum the contents of the resource are
+// stored under: its digest or, for resources without one, the
+// checksum recorded when they were fetched. Returns an empty string
+// if neither is known.
+func checksum(database db.Database, resource *cap.Resource, loc *location) (string, error) {
+	if resource.Digest != "" {
+		return resource.Digest, nil
+	}
+
+	status, err := database.GetResourceStatus(loc.alertId, loc.info, loc.resource)
+	if err == db.ErrNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if status.Status != db.ResourceFetched {
+		return "", nil
+	}
+
+	return status.Checksum, nil
+}
+
+// contents opens the contents of the resource, from (in order) its
+// inline contents, the resource store (where they are put by the
+// fetch_resources task, under their checksum) or, if enabled by
+// CAP_RESOURCE_FETCH, its uri. Returns them along with their size.
+func contents(resource *cap.Resource, checksum string, resources store.ResourceStore) (io.ReadCloser, int64, error) {
+	if resource.DerefUri != nil {
+		b, err := base64.StdEncoding.DecodeString(*resource.DerefUri)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return ioutil.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+	}
+
+	if checksum != "" {
+		info, err := resources.Stat(checksum)
+		if err == nil {
+			r, err := resources.Get(checksum)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			return r, info.Size, nil
+		} else if err != store.ErrNotFound {
+			return nil, 0, err
+		}
+	}
+
+	if !fetchEnabled() {
+		return nil, 0, errNoContents
+	}
+
+	// Only remote resources, not local files and the like
+	uri, err := url.Parse(resource.Uri)
+	if err != nil || (uri.Scheme != "http" && uri.Scheme != "https") {
+		return nil, 0, errNoContents
+	}
+
+	return fetch(uri)
+}
+
+// fetchEnabled returns whether resources missing from the store
+// may be fetched from their uri, as set by CAP_RESOURCE_FETCH.
+func fetchEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv("CAP_RESOURCE_FETCH"))
+	return err == nil && enabled
+}
+
+// fetch opens the remote resource at uri, returning its body along
+// with its size. The body is streamed when the server gives its size,
+// leaving read to check it, and read up to the largest size allowed
+// otherwise.
+func fetch(uri *url.URL) (io.ReadCloser, int64, error) {
+	client := http.Client{Timeout: fetchTimeout}
+
+	res, err := client.Get(uri.String())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, 0, fmt.Errorf("Unexpected status fetching %s: %s", uri, res.Status)
+	}
+
+	max := maxSize()
+	if res.ContentLength > max {
+		res.Body.Close()
+		return nil, 0, fmt.Errorf("Resource %s is larger than %d bytes", uri, max)
+	} else if res.ContentLength >= 0 {
+		return res.Body, res.ContentLength, nil
+	}
+
+	// The size is unknown (ie. the body is chunked)
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(&io.LimitedReader{R: res.Body, N: max + 1})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if int64(len(b)) > max {
+		return nil, 0, fmt.Errorf("Resource %s is larger than %d bytes", uri, max)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+}
+
+// read reads the size bytes of the contents from r, and returns
+// those in the range (all of them if nil). The contents are checked
+// against their checksum (SHA-1) as they are read, so only the range
+// is kept in memory.
+func read(r io.Reader, size int64, rng *byteRange, checksum string) ([]byte, error) {
+	hash := sha1.New()
+	part := newRangeWriter(rng, size)
+
+	n, err := io.Copy(io.MultiWriter(hash, part), io.LimitReader(r, size+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if n != size {
+		return nil, fmt.Errorf("Size mismatch, got: %d bytes, want: %d", n, size)
+	}
+
+	if checksum != "" {
+		if digest := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(digest, checksum) {
+			return nil, fmt.Errorf("Digest mismatch, got: %s, want: %s", digest, checksum)
+		}
+	}
+
+	return part.Bytes(), nil
+}
@@ -0,0 +1,379 @@
+package function
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openfaas-incubator/go-function-sdk"
+
+	"github.com/alerting/go-cap"
+	"github.com/alerting/go-cap-process/db"
+	"github.com/alerting/go-cap-process/db/memory"
+	"github.com/alerting/go-cap-process/store/fs"
+)
+
+const (
+	helloWorld       = "hello world"
+	helloWorldDigest = "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"
+	otherDigest      = "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"
+)
+
+// setenv sets the environment variable, and returns a function restoring it.
+func setenv(name, value string) func() {
+	old, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+
+	return func() {
+		if ok {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// newStore creates a filesystem store with hello world in it, and
+// returns a function removing it.
+func newStore(t *testing.T) (*fs.Store, func()) {
+	dir, err := ioutil.TempDir("", "cap-resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources, err := fs.CreateStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := resources.Put(helloWorldDigest, strings.NewReader(helloWorld)); err != nil {
+		t.Fatal(err)
+	}
+
+	return resources, func() { os.RemoveAll(dir) }
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		size     int64
+		rng      *byteRange
+		checksum string
+		want     string
+		err      string
+	}{
+		{11, nil, helloWorldDigest, helloWorld, ""},
+		{11, &byteRange{6, 10}, helloWorldDigest, "world", ""},
+		{11, nil, strings.ToUpper(helloWorldDigest), helloWorld, ""},
+
+		// Without a checksum, the contents are not checked
+		{11, nil, "", helloWorld, ""},
+
+		// The whole contents are checked, even for a range
+		{11, nil, otherDigest, "", "Digest mismatch"},
+		{11, &byteRange{0, 4}, otherDigest, "", "Digest mismatch"},
+
+		// Contents of the wrong size
+		{20, nil, helloWorldDigest, "", "Size mismatch"},
+		{5, &byteRange{0, 4}, "", "", "Size mismatch"},
+	}
+
+	for _, test := range tests {
+		b, err := read(strings.NewReader(helloWorld), test.size, test.rng, test.checksum)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Unexpected error for %+v, got: %v, want: %s.", test, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for %+v: %v", test, err)
+		} else if string(b) != test.want {
+			t.Errorf("Unexpected contents for %+v, got: %q, want: %q.", test, b, test.want)
+		}
+	}
+}
+
+func TestContents(t *testing.T) {
+	resources, cleanup := newStore(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hello.txt" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(helloWorld))
+	}))
+	defer server.Close()
+
+	deref := base64.StdEncoding.EncodeToString([]byte(helloWorld))
+
+	tests := []struct {
+		name     string
+		resource cap.Resource
+		checksum string
+		fetch    string
+		want     string
+		err      string
+	}{
+		{"inline", cap.Resource{DerefUri: &deref}, "", "", helloWorld, ""},
+		{"store", cap.Resource{Uri: server.URL + "/missing"}, helloWorldDigest, "", helloWorld, ""},
+
+		// Fetching from the uri is opt-in
+		{"not stored", cap.Resource{Uri: server.URL + "/hello.txt"}, otherDigest, "", "", errNoContents.Error()},
+		{"fetched", cap.Resource{Uri: server.URL + "/hello.txt"}, otherDigest, "true", helloWorld, ""},
+		{"not found", cap.Resource{Uri: server.URL + "/missing"}, "", "true", "", "404"},
+
+		// And only over http(s)
+		{"file", cap.Resource{Uri: "file:///etc/passwd"}, "", "true", "", errNoContents.Error()},
+		{"no uri", cap.Resource{}, "", "true", "", errNoContents.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer setenv("CAP_RESOURCE_FETCH", test.fetch)()
+
+			r, size, err := contents(&test.resource, test.checksum, resources)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Unexpected error, got: %v, want: %s.", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.want || size != int64(len(test.want)) {
+				t.Errorf("Unexpected contents, got: %q (%d bytes), want: %q.", b, size, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing first leaves the size out, so the body is chunked
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+
+		w.Write([]byte(helloWorld))
+	}))
+	defer server.Close()
+
+	defer setenv("CAP_RESOURCE_FETCH", "true")()
+
+	tests := []struct {
+		path string
+		max  string
+		err  string
+	}{
+		{"/sized", "", ""},
+		{"/sized", "5", "larger than 5 bytes"},
+		{"/chunked", "", ""},
+		{"/chunked", "5", "larger than 5 bytes"},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer setenv("CAP_RESOURCE_MAX_SIZE", test.max)()
+
+			r, size, err := contents(&cap.Resource{Uri: server.URL + test.path}, "", nil)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Unexpected error for %s (max %q), got: %v, want: %s.", test.path, test.max, err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Errorf("Unexpected error for %s (max %q): %v", test.path, test.max, err)
+				return
+			}
+			defer r.Close()
+
+			// The contents are streamed through read
+			b, err := read(r, size, nil, helloWorldDigest)
+			if err != nil {
+				t.Errorf("Unexpected error reading %s: %v", test.path, err)
+			} else if string(b) != helloWorld {
+				t.Errorf("Unexpected contents of %s, got: %q, want: %q.", test.path, b, helloWorld)
+			}
+		}()
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	database, err := memory.CreateDatabase("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var alert cap.Alert
+	err = xml.Unmarshal([]byte(`<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+  <identifier>resources-2018-001</identifier>
+  <sender>cap@example.ca</sender>
+  <sent>2018-06-02T09:00:00-07:00</sent>
+  <status>Actual</status>
+  <msgType>Alert</msgType>
+  <scope>Public</scope>
+  <info>
+    <category>Met</category>
+    <event>rainfall</event>
+    <urgency>Future</urgency>
+    <severity>Moderate</severity>
+    <certainty>Possible</certainty>
+    <resource>
+      <resourceDesc>Map</resourceDesc>
+      <mimeType>image/png</mimeType>
+      <uri>http://example.ca/map.png</uri>
+      <digest>`+otherDigest+`</digest>
+    </resource>
+  </info>
+  <info>
+    <category>Met</category>
+    <event>rainfall</event>
+    <urgency>Future</urgency>
+    <severity>Moderate</severity>
+    <certainty>Possible</certainty>
+    <resource>
+      <resourceDesc>Text</resourceDesc>
+      <mimeType>text/plain</mimeType>
+      <uri>http://example.ca/hello.txt</uri>
+    </resource>
+  </info>
+</alert>`), &alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.AddAlert(&alert); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second resource of the alert is the first of the second info
+	resource, loc, err := resourceByIndex(database, alert.Id(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *loc != (location{alertId: alert.Id(), info: 1, resource: 0}) {
+		t.Errorf("Unexpected location, got: %+v", loc)
+	}
+
+	// Not fetched yet
+	if sum, err := checksum(database, resource, loc); err != nil || sum != "" {
+		t.Errorf("Unexpected checksum, got: %q (%v), want: none.", sum, err)
+	}
+
+	// Failed fetches have no checksum to look up
+	if err := database.SetResourceStatus(alert.Id(), 1, 0, &db.ResourceStatus{Status: db.ResourceFailed, Checksum: otherDigest}); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum, err := checksum(database, resource, loc); err != nil || sum != "" {
+		t.Errorf("Unexpected checksum, got: %q (%v), want: none.", sum, err)
+	}
+
+	if err := database.SetResourceStatus(alert.Id(), 1, 0, &db.ResourceStatus{Status: db.ResourceFetched, Checksum: helloWorldDigest}); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum, err := checksum(database, resource, loc); err != nil || sum != helloWorldDigest {
+		t.Errorf("Unexpected checksum, got: %q (%v), want: %s.", sum, err, helloWorldDigest)
+	}
+
+	// The digest is used when there is one
+	resource, loc, err = resourceByIndex(database, alert.Id(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum, err := checksum(database, resource, loc); err != nil || sum != otherDigest {
+		t.Errorf("Unexpected checksum, got: %q (%v), want: %s.", sum, err, otherDigest)
+	}
+
+	if _, _, err := resourceByIndex(database, alert.Id(), 2); err != db.ErrNotFound {
+		t.Errorf("Unexpected error, got: %v, want: %v.", err, db.ErrNotFound)
+	}
+}
+
+func TestResourceResponse(t *testing.T) {
+	resource := &cap.Resource{MimeType: "text/plain"}
+
+	tests := []struct {
+		method   string
+		rng      string
+		checksum string
+		status   int
+		headers  map[string]string
+		body     string
+	}{
+		{http.MethodGet, "", helloWorldDigest, http.StatusOK, map[string]string{
+			"Content-Type":   "text/plain",
+			"Content-Length": "11",
+			"ETag":           `"` + helloWorldDigest + `"`,
+		}, helloWorld},
+		{http.MethodHead, "", helloWorldDigest, http.StatusOK, map[string]string{
+			"Content-Length": "11",
+		}, ""},
+		{http.MethodGet, "bytes=-5", helloWorldDigest, http.StatusPartialContent, map[string]string{
+			"Content-Length": "5",
+			"Content-Range":  "bytes 6-10/11",
+		}, "world"},
+		{http.MethodGet, "bytes=0-4,6-10", helloWorldDigest, http.StatusOK, map[string]string{
+			"Content-Length": "11",
+		}, helloWorld},
+		{http.MethodGet, "bytes=11-", helloWorldDigest, http.StatusRequestedRangeNotSatisfiable, map[string]string{
+			"Content-Range": "bytes */11",
+		}, ""},
+	}
+
+	for _, test := range tests {
+		req := handler.Request{
+			Method: test.method,
+			Header: http.Header{},
+		}
+		if test.rng != "" {
+			req.Header.Set("Range", test.rng)
+		}
+
+		res, err := resourceResponse(req, resource, test.checksum, strings.NewReader(helloWorld), int64(len(helloWorld)))
+		if err != nil {
+			t.Errorf("Unexpected error for %s %q: %v", test.method, test.rng, err)
+			continue
+		}
+
+		if res.StatusCode != test.status {
+			t.Errorf("Unexpected status for %s %q, got: %d, want: %d.", test.method, test.rng, res.StatusCode, test.status)
+		}
+
+		for name, value := range test.headers {
+			if got := res.Header.Get(name); got != value {
+				t.Errorf("Unexpected %s for %s %q, got: %q, want: %q.", name, test.method, test.rng, got, value)
+			}
+		}
+
+		if test.status != http.StatusRequestedRangeNotSatisfiable && !bytes.Equal(res.Body, []byte(test.body)) {
+			t.Errorf("Unexpected body for %s %q, got: %q, want: %q.", test.method, test.rng, res.Body, test.body)
+		}
+	}
+
+	// Contents not matching their digest are not sent
+	_, err := resourceResponse(handler.Request{Method: http.MethodGet, Header: http.Header{}}, resource, otherDigest, strings.NewReader(helloWorld), int64(len(helloWorld)))
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusBadGateway {
+		t.Errorf("Unexpected error, got: %v", err)
+	}
+}